@@ -12,6 +12,42 @@ import (
 	"strings"
 )
 
+// contentUnchangedSinceLastParse reports whether previousHash is a real,
+// recorded hash (not the zero value for a filing we've never parsed) that
+// matches the filing's current ContentHash -- i.e. it's safe to skip
+// reparsing. previousHash must come from ScrapeState.LastParsedContentHash,
+// not from the ReportFile being checked itself -- that field only gets
+// stamped by a completed parse, so it's the genuinely distinct "hash as of
+// last successful parse" this comparison needs.
+func contentUnchangedSinceLastParse(previousHash, currentHash string) bool {
+	return previousHash != "" && previousHash == currentHash
+}
+
+// markParsed records that reportFile has been run through the parser,
+// updating the report file itself and, when the parse succeeded, the
+// ScrapeState's LastParsedContentHash so a future run can tell a genuine
+// re-parse from a filing that's just sitting unchanged. A parse that failed
+// leaves ScrapeState untouched.
+func markParsed(reportPersister persist.PersistReportFiles, statePersister persist.PersistScrapeState,
+	reportFile *filings.ReportFile, parsedWithError bool) {
+	reportFile.Parsed = true
+	reportFile.ParsedWithError = parsedWithError
+	reportPersister.InsertUpdateReportFile(reportFile)
+
+	if parsedWithError {
+		return
+	}
+
+	state := statePersister.GetScrapeState(reportFile.CIK, reportFile.Year, reportFile.Quarter, reportFile.FormType)
+	if state == nil {
+		state = &filings.ScrapeState{CIK: reportFile.CIK, Year: reportFile.Year,
+			Quarter: reportFile.Quarter, FormType: reportFile.FormType}
+	}
+	state.Status = filings.ScrapeStatusParsed
+	state.LastParsedContentHash = reportFile.ContentHash
+	statePersister.InsertUpdateScrapeState(state)
+}
+
 //func init() {
 //	flag.IntVar(&year, "year", 0, "The year to scrape")
 //	flag.IntVar(&quarter, "quarter", 0, "The quarter to scrape")
@@ -32,6 +68,7 @@ func main() {
 	reportPersister := persist.NewMongoDbReportFiles(c.MongoHost, c.MongoDb)
 	rawReportPersister :=
 		persist.NewMongoDbFinancialReportsRaw(c.MongoHost, c.MongoDb)
+	statePersister := persist.NewMongoDbScrapeState(c.MongoHost, c.MongoDb)
 
 	var batchLimit int64 = 20
 
@@ -64,6 +101,19 @@ func main() {
 				continue
 			}
 
+			state := statePersister.GetScrapeState(reportFile.CIK, reportFile.Year, reportFile.Quarter, reportFile.FormType)
+			var previousHash string
+			if state != nil {
+				previousHash = state.LastParsedContentHash
+			}
+			if contentUnchangedSinceLastParse(previousHash, reportFile.ContentHash) {
+				log.Println("SKIP parsing <", reportFile.Filepath, "> because its content hash hasn't changed since the last parse")
+				reportFile.Parsed = true
+
+				reportPersister.InsertUpdateReportFile(&reportFile)
+				continue
+			}
+
 			rawReport := &filings.FinancialReportRaw{CIK: reportFile.CIK, Year: reportFile.Year, Quarter: reportFile.Quarter}
 			// TODO this is not optimal
 			rawReport.RawFields = make(map[string]int64)
@@ -71,7 +121,14 @@ func main() {
 			frp := parser.NewFinancialReportParser(reportFile.Filepath,
 				rawReport, rawReportPersister, &filings.BasicRawFieldNameList{})
 
-			frp.Parse()
+			if parseErr := frp.Parse(); parseErr != nil {
+				log.Error("Failed to parse CIK <", reportFile.CIK, "> year <", reportFile.Year,
+					"> quarter <", reportFile.Quarter, "> with error: ", parseErr)
+
+				markParsed(reportPersister, statePersister, &reportFile, true)
+				numInvalid++
+				continue
+			}
 
 			fr := frp.GetFinancialReport()
 
@@ -88,9 +145,7 @@ func main() {
 				numInvalid++
 			}
 
-			reportFile.Parsed = true
-
-			reportPersister.InsertUpdateReportFile(&reportFile)
+			markParsed(reportPersister, statePersister, &reportFile, false)
 		}
 
 		totalNumInvalid += numInvalid
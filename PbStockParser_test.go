@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ProfessorBeekums/PbStockResearcher/filings"
+	"testing"
+)
+
+type fakeReportFilePersister struct {
+	updated []filings.ReportFile
+}
+
+func (f *fakeReportFilePersister) GetNextUnparsedFiles(limit int64) *[]filings.ReportFile {
+	return nil
+}
+
+func (f *fakeReportFilePersister) InsertUpdateReportFile(reportFile *filings.ReportFile) {
+	f.updated = append(f.updated, *reportFile)
+}
+
+type fakeScrapeStatePersister struct {
+	states map[string]*filings.ScrapeState
+}
+
+func newFakeScrapeStatePersister() *fakeScrapeStatePersister {
+	return &fakeScrapeStatePersister{states: make(map[string]*filings.ScrapeState)}
+}
+
+func (f *fakeScrapeStatePersister) key(cik, year, quarter int64, formType string) string {
+	return fmt.Sprintf("%d:%d:%d:%s", cik, year, quarter, formType)
+}
+
+func (f *fakeScrapeStatePersister) GetScrapeState(cik, year, quarter int64, formType string) *filings.ScrapeState {
+	return f.states[f.key(cik, year, quarter, formType)]
+}
+
+func (f *fakeScrapeStatePersister) InsertUpdateScrapeState(state *filings.ScrapeState) {
+	f.states[f.key(state.CIK, state.Year, state.Quarter, state.FormType)] = state
+}
+
+// TestMarkParsedThenSkipsUnchangedContent proves that the hash used to
+// decide "skip reparsing" comes from ScrapeState.LastParsedContentHash --
+// recorded only once markParsed runs -- and not from the ReportFile's own
+// ContentHash, which is already set the very first time a never-parsed
+// filing is seen. Without that distinction every brand-new filing would
+// trivially "match itself" and never actually reach the parser.
+func TestMarkParsedThenSkipsUnchangedContent(t *testing.T) {
+	reportPersister := &fakeReportFilePersister{}
+	statePersister := newFakeScrapeStatePersister()
+
+	reportFile := &filings.ReportFile{CIK: 1750, Year: 2020, Quarter: 1,
+		FormType: "10-K", ContentHash: "abc123"}
+
+	state := statePersister.GetScrapeState(reportFile.CIK, reportFile.Year, reportFile.Quarter, reportFile.FormType)
+	if state != nil {
+		t.Fatalf("expected no recorded state for a never-parsed filing, got %+v", state)
+	}
+
+	var previousHash string
+	if state != nil {
+		previousHash = state.LastParsedContentHash
+	}
+	if contentUnchangedSinceLastParse(previousHash, reportFile.ContentHash) {
+		t.Fatal("a filing with no recorded parse state must never be treated as unchanged")
+	}
+
+	markParsed(reportPersister, statePersister, reportFile, false)
+
+	state = statePersister.GetScrapeState(reportFile.CIK, reportFile.Year, reportFile.Quarter, reportFile.FormType)
+	if state == nil || state.LastParsedContentHash != "abc123" {
+		t.Fatalf("expected markParsed to record LastParsedContentHash, got %+v", state)
+	}
+
+	if !contentUnchangedSinceLastParse(state.LastParsedContentHash, reportFile.ContentHash) {
+		t.Fatal("expected a second pass with the same content hash to be skippable after a successful parse")
+	}
+
+	amended := &filings.ReportFile{CIK: 1750, Year: 2020, Quarter: 1,
+		FormType: "10-K", ContentHash: "def456"}
+	if contentUnchangedSinceLastParse(state.LastParsedContentHash, amended.ContentHash) {
+		t.Fatal("an amended filing with a new content hash must not be skipped")
+	}
+}
+
+func TestContentUnchangedSinceLastParse(t *testing.T) {
+	cases := []struct {
+		name                      string
+		previousHash, currentHash string
+		expectUnchanged           bool
+	}{
+		{"never parsed before", "", "abc123", false},
+		{"hash matches", "abc123", "abc123", true},
+		{"hash changed (amendment)", "abc123", "def456", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := contentUnchangedSinceLastParse(c.previousHash, c.currentHash)
+			if got != c.expectUnchanged {
+				t.Errorf("contentUnchangedSinceLastParse(%q, %q) = %v, want %v",
+					c.previousHash, c.currentHash, got, c.expectUnchanged)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package filings
+
+// ScrapeStatus is where a single (CIK, year, quarter, formType) filing sits
+// in the scrape pipeline.
+type ScrapeStatus string
+
+const (
+	ScrapeStatusPending        ScrapeStatus = "pending"
+	ScrapeStatusDownloaded     ScrapeStatus = "downloaded"
+	ScrapeStatusExtracted      ScrapeStatus = "extracted"
+	ScrapeStatusParsed         ScrapeStatus = "parsed"
+	ScrapeStatusPermanentError ScrapeStatus = "permanent-error"
+)
+
+// ScrapeState is the resumable bookkeeping record for one filing: where it
+// is in the pipeline, what went wrong last (if anything), and how many
+// times we've tried. A crawl that gets interrupted reads these back out so
+// filings already recorded as a permanent error aren't fetched again.
+type ScrapeState struct {
+	CIK, Year, Quarter int64
+	FormType           string
+	Status             ScrapeStatus
+	LastError          string
+	Attempts           int64
+	// LastParsedContentHash is the ReportFile.ContentHash as of the last
+	// successful parse, not the hash of whatever was most recently fetched
+	// -- the two only line up once the parse step has actually run on that
+	// content, so comparing against this (instead of a ReportFile's own,
+	// just-stamped ContentHash) is what makes re-parse skipping meaningful.
+	LastParsedContentHash string
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"encoding/xml"
+	"github.com/ProfessorBeekums/PbStockResearcher/filings"
+	"github.com/ProfessorBeekums/PbStockResearcher/persist"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FinancialReportParser streams the XBRL XML at filepath, picking out the
+// int64-valued tags fieldNames cares about into rawReport, then persists the
+// raw fields. The decode is always run through ParserLimits so a
+// pathological or hostile document can't blow the stack or exhaust memory.
+type FinancialReportParser struct {
+	filepath   string
+	rawReport  *filings.FinancialReportRaw
+	persister  persist.PersistFinancialReportsRaw
+	fieldNames filings.RawFieldNameList
+	limits     ParserLimits
+}
+
+func NewFinancialReportParser(filepath string, rawReport *filings.FinancialReportRaw,
+	persister persist.PersistFinancialReportsRaw, fieldNames filings.RawFieldNameList) *FinancialReportParser {
+	return &FinancialReportParser{filepath: filepath, rawReport: rawReport,
+		persister: persister, fieldNames: fieldNames, limits: DefaultParserLimits()}
+}
+
+// NewFinancialReportParserWithLimits is the same as NewFinancialReportParser
+// but lets a caller override the default safety limits, e.g. to relax
+// MaxDepth for a filing that's known to legitimately nest deeply.
+func NewFinancialReportParserWithLimits(filepath string, rawReport *filings.FinancialReportRaw,
+	persister persist.PersistFinancialReportsRaw, fieldNames filings.RawFieldNameList,
+	limits ParserLimits) *FinancialReportParser {
+	return &FinancialReportParser{filepath: filepath, rawReport: rawReport,
+		persister: persister, fieldNames: fieldNames, limits: limits}
+}
+
+// Parse streams the XBRL document and populates rawReport.RawFields. It
+// returns ErrXMLLimitExceeded (wrapped, so errors.Is still matches) if the
+// document crosses one of the configured ParserLimits, so the caller can
+// mark the filing as parsed-with-error and move on to the next one instead
+// of risking a stack overflow on a maliciously deep document.
+func (frp *FinancialReportParser) Parse() error {
+	file, openErr := os.Open(frp.filepath)
+	if openErr != nil {
+		return openErr
+	}
+	defer file.Close()
+
+	wantNames := make(map[string]bool)
+	for _, name := range frp.fieldNames.GetInt64RawFieldNames() {
+		wantNames[name] = true
+	}
+
+	decoder := newLimitedXmlDecoder(file, frp.limits)
+
+	var currentName string
+	for {
+		tok, tokErr := decoder.Token()
+		if tokErr == io.EOF {
+			break
+		}
+		if tokErr != nil {
+			return tokErr
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			currentName = t.Name.Local
+		case xml.CharData:
+			if wantNames[currentName] {
+				if value, convErr := strconv.ParseInt(strings.TrimSpace(string(t)), 10, 64); convErr == nil {
+					frp.rawReport.RawFields[currentName] = value
+				}
+			}
+		}
+	}
+
+	if frp.persister != nil {
+		frp.persister.InsertRawFinancialReport(frp.rawReport)
+	}
+
+	return nil
+}
+
+// GetFinancialReport maps the raw fields collected by Parse into a
+// FinancialReport, summing every candidate tag in whichever group of
+// BasicRawToScreenableMapping's candidates is present (e.g. TotalLiabilities
+// adds LiabilitiesCurrent + DeferredTaxLiabilitiesNoncurrent +
+// LongTermDebtNoncurrent when that group is the one reported).
+func (frp *FinancialReportParser) GetFinancialReport() *filings.FinancialReport {
+	fr := &filings.FinancialReport{
+		CIK:     frp.rawReport.CIK,
+		Year:    frp.rawReport.Year,
+		Quarter: frp.rawReport.Quarter,
+	}
+
+	mapping := (&filings.BasicRawToScreenableMapping{}).GetRawToScreenableMapping(fr)
+
+	for target, candidateGroups := range mapping {
+		for _, names := range candidateGroups {
+			var sum int64
+			matched := false
+
+			for _, name := range names {
+				if value, ok := frp.rawReport.RawFields[name]; ok {
+					sum += value
+					matched = true
+				}
+			}
+
+			if matched {
+				*target = sum
+				break
+			}
+		}
+	}
+
+	return fr
+}
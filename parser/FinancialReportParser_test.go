@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"errors"
+	"github.com/ProfessorBeekums/PbStockResearcher/filings"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeFinancialReportsRawPersister struct {
+	inserted *filings.FinancialReportRaw
+}
+
+func (f *fakeFinancialReportsRawPersister) InsertRawFinancialReport(raw *filings.FinancialReportRaw) {
+	f.inserted = raw
+}
+
+func writeTempXbrl(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test-xbrl.xml")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	return path
+}
+
+func TestFinancialReportParserExtractsRawFields(t *testing.T) {
+	path := writeTempXbrl(t, `<xbrl><Revenues>1000</Revenues><NetIncomeLoss>42</NetIncomeLoss></xbrl>`)
+
+	rawReport := &filings.FinancialReportRaw{CIK: 1, Year: 2020, Quarter: 1, RawFields: make(map[string]int64)}
+	persister := &fakeFinancialReportsRawPersister{}
+
+	frp := NewFinancialReportParser(path, rawReport, persister, &filings.BasicRawFieldNameList{})
+
+	if err := frp.Parse(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if rawReport.RawFields["Revenues"] != 1000 {
+		t.Errorf("expected Revenues=1000, got %d", rawReport.RawFields["Revenues"])
+	}
+	if rawReport.RawFields["NetIncomeLoss"] != 42 {
+		t.Errorf("expected NetIncomeLoss=42, got %d", rawReport.RawFields["NetIncomeLoss"])
+	}
+	if persister.inserted != rawReport {
+		t.Errorf("expected the raw report to be persisted")
+	}
+}
+
+func TestFinancialReportParserReturnsLimitExceededOnPathologicalNesting(t *testing.T) {
+	nested := ""
+	for i := 0; i < 10; i++ {
+		nested += "<a>"
+	}
+	nested += "deep"
+	for i := 0; i < 10; i++ {
+		nested += "</a>"
+	}
+
+	path := writeTempXbrl(t, nested)
+
+	rawReport := &filings.FinancialReportRaw{CIK: 1, Year: 2020, Quarter: 1, RawFields: make(map[string]int64)}
+	frp := NewFinancialReportParserWithLimits(path, rawReport, nil, &filings.BasicRawFieldNameList{},
+		ParserLimits{MaxDepth: 3})
+
+	err := frp.Parse()
+	if !errors.Is(err, ErrXMLLimitExceeded) {
+		t.Fatalf("expected ErrXMLLimitExceeded, got %v", err)
+	}
+}
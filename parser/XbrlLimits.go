@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrXMLLimitExceeded is returned by limitedXmlDecoder.Token when a
+// configured safety limit is hit. Callers can match it with errors.Is to
+// distinguish a pathological/hostile document from a genuine parse error.
+var ErrXMLLimitExceeded = errors.New("xbrl xml exceeded a configured safety limit")
+
+// ParserLimits bounds how much of an XBRL document FinancialReportParser is
+// willing to stream before giving up. EDGAR has shipped filings with
+// pathological nesting before (the same class of bug has bitten
+// encoding/xml upstream), so these are enforced unconditionally rather than
+// left to OS-level resource limits.
+type ParserLimits struct {
+	// MaxDepth is the deepest an element may nest. 0 means unlimited.
+	MaxDepth int
+	// MaxTokens is the total number of xml.Token values the decoder may
+	// emit for one document. 0 means unlimited.
+	MaxTokens int64
+	// MaxAttributes is the most attributes a single element may carry.
+	// 0 means unlimited.
+	MaxAttributes int
+	// MaxBytes is the most the underlying reader may be asked for. 0
+	// means unlimited.
+	MaxBytes int64
+}
+
+// DefaultParserLimits are generous enough for every XBRL filing seen in
+// practice while still rejecting a document that's clearly gone wrong.
+func DefaultParserLimits() ParserLimits {
+	return ParserLimits{
+		MaxDepth:      256,
+		MaxTokens:     5_000_000,
+		MaxAttributes: 256,
+		MaxBytes:      512 * 1024 * 1024,
+	}
+}
+
+// limitedXmlDecoder wraps an *xml.Decoder reading from a byte-budgeted
+// source and enforces ParserLimits while tokens are streamed out, so a
+// malicious or accidentally deep document is rejected before it can blow
+// the stack or exhaust memory.
+type limitedXmlDecoder struct {
+	decoder *xml.Decoder
+	limits  ParserLimits
+
+	depth      int
+	tokenCount int64
+}
+
+// newLimitedXmlDecoder wraps r with a byte budget and returns a decoder that
+// enforces limits as tokens are pulled from it.
+func newLimitedXmlDecoder(r io.Reader, limits ParserLimits) *limitedXmlDecoder {
+	budgeted := r
+	if limits.MaxBytes > 0 {
+		budgeted = io.LimitReader(r, limits.MaxBytes)
+	}
+
+	return &limitedXmlDecoder{decoder: xml.NewDecoder(budgeted), limits: limits}
+}
+
+// Token returns the next token, or an error wrapping ErrXMLLimitExceeded
+// once depth, token count, attribute count, or the byte budget crosses the
+// configured limit.
+func (d *limitedXmlDecoder) Token() (xml.Token, error) {
+	tok, err := d.decoder.Token()
+	if err != nil {
+		// the byte budget cuts the document off mid-stream, which the xml
+		// decoder otherwise reports as a plain syntax error
+		if err != io.EOF && d.limits.MaxBytes > 0 && d.decoder.InputOffset() >= d.limits.MaxBytes {
+			return nil, fmt.Errorf("%w: exceeded max byte budget (%d)", ErrXMLLimitExceeded, d.limits.MaxBytes)
+		}
+		return nil, err
+	}
+
+	d.tokenCount++
+	if d.limits.MaxTokens > 0 && d.tokenCount > d.limits.MaxTokens {
+		return nil, fmt.Errorf("%w: exceeded max token count (%d)", ErrXMLLimitExceeded, d.limits.MaxTokens)
+	}
+
+	switch t := tok.(type) {
+	case xml.StartElement:
+		d.depth++
+		if d.limits.MaxDepth > 0 && d.depth > d.limits.MaxDepth {
+			return nil, fmt.Errorf("%w: exceeded max nesting depth (%d)", ErrXMLLimitExceeded, d.limits.MaxDepth)
+		}
+		if d.limits.MaxAttributes > 0 && len(t.Attr) > d.limits.MaxAttributes {
+			return nil, fmt.Errorf("%w: exceeded max attribute count (%d) on <%s>", ErrXMLLimitExceeded, d.limits.MaxAttributes, t.Name.Local)
+		}
+	case xml.EndElement:
+		d.depth--
+	}
+
+	return tok, nil
+}
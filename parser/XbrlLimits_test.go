@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func drainTokens(d *limitedXmlDecoder) error {
+	for {
+		_, err := d.Token()
+		if err == nil {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+}
+
+func TestLimitedXmlDecoderEnforcesMaxDepth(t *testing.T) {
+	doc := strings.NewReader("<a><b><c><d>deep</d></c></b></a>")
+	d := newLimitedXmlDecoder(doc, ParserLimits{MaxDepth: 2})
+
+	err := drainTokens(d)
+	if !errors.Is(err, ErrXMLLimitExceeded) {
+		t.Fatalf("expected ErrXMLLimitExceeded, got %v", err)
+	}
+}
+
+func TestLimitedXmlDecoderEnforcesMaxAttributes(t *testing.T) {
+	doc := strings.NewReader(`<a x="1" y="2" z="3"></a>`)
+	d := newLimitedXmlDecoder(doc, ParserLimits{MaxAttributes: 2})
+
+	err := drainTokens(d)
+	if !errors.Is(err, ErrXMLLimitExceeded) {
+		t.Fatalf("expected ErrXMLLimitExceeded, got %v", err)
+	}
+}
+
+func TestLimitedXmlDecoderEnforcesMaxTokens(t *testing.T) {
+	doc := strings.NewReader("<a><b/><c/><d/></a>")
+	d := newLimitedXmlDecoder(doc, ParserLimits{MaxTokens: 2})
+
+	err := drainTokens(d)
+	if !errors.Is(err, ErrXMLLimitExceeded) {
+		t.Fatalf("expected ErrXMLLimitExceeded, got %v", err)
+	}
+}
+
+func TestLimitedXmlDecoderAllowsWellFormedDocumentUnderLimits(t *testing.T) {
+	doc := strings.NewReader("<a><b><c>hello</c></b></a>")
+	d := newLimitedXmlDecoder(doc, DefaultParserLimits())
+
+	if err := drainTokens(d); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,9 @@
+package persist
+
+import "github.com/ProfessorBeekums/PbStockResearcher/filings"
+
+// PersistFinancialReportsRaw stores the raw tag -> value fields pulled off
+// an XBRL filing's XML by FinancialReportParser.
+type PersistFinancialReportsRaw interface {
+	InsertRawFinancialReport(raw *filings.FinancialReportRaw)
+}
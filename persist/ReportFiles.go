@@ -0,0 +1,9 @@
+package persist
+
+import "github.com/ProfessorBeekums/PbStockResearcher/filings"
+
+// PersistReportFiles stores the downloaded/extracted state of each filing.
+type PersistReportFiles interface {
+	GetNextUnparsedFiles(limit int64) *[]filings.ReportFile
+	InsertUpdateReportFile(reportFile *filings.ReportFile)
+}
@@ -0,0 +1,11 @@
+package persist
+
+import "github.com/ProfessorBeekums/PbStockResearcher/filings"
+
+// PersistScrapeState stores per-(CIK, year, quarter, formType) crawl
+// progress. GetScrapeState returns nil when nothing has been recorded yet
+// for that key.
+type PersistScrapeState interface {
+	GetScrapeState(cik, year, quarter int64, formType string) *filings.ScrapeState
+	InsertUpdateScrapeState(state *filings.ScrapeState)
+}
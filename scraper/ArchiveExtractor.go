@@ -0,0 +1,157 @@
+package scraper
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/nwaples/rardecode"
+	"io"
+	"strings"
+)
+
+// archiveReadBufferSize is how much of the archive httpRangeReaderAt pulls
+// per HTTP Range GET for the tar.gz and rar extractors. Both formats are
+// forward-only, so without this the small reads gzip/tar/rardecode issue
+// internally would each turn into their own Range GET -- one archive could
+// cost thousands of round trips instead of the handful this buffer size
+// works out to.
+const archiveReadBufferSize = 256 * 1024
+
+// ArchiveExtractor pulls a single matching member out of an archive without
+// the caller having to know which archive format it is. Implementations get
+// random access to the whole archive via r/size (so a zip implementation can
+// jump straight to the central directory) but only ever need to return the
+// one member `want` selects.
+type ArchiveExtractor interface {
+	// Match reports whether this extractor handles filename, based on its
+	// extension.
+	Match(filename string) bool
+	// Extract scans the archive and returns the first member for which
+	// want(name) is true.
+	Extract(r io.ReaderAt, size int64, want func(name string) bool) (io.ReadCloser, error)
+}
+
+// archiveExtractors is consulted in order, so register more specific
+// extensions (e.g. ".tar.gz") before more general ones if that's ever a
+// concern.
+var archiveExtractors = []ArchiveExtractor{
+	&zipArchiveExtractor{},
+	&targzArchiveExtractor{},
+	&rarArchiveExtractor{},
+}
+
+// extractorFor returns the registered ArchiveExtractor whose Match accepts
+// filename, so GetXbrl can dispatch on the URL suffix instead of hardcoding
+// archive/zip. An unrecognized suffix is a permanent error -- trying the
+// same URL again won't make an extractor appear for it.
+func extractorFor(filename string) (ArchiveExtractor, error) {
+	for _, extractor := range archiveExtractors {
+		if extractor.Match(filename) {
+			return extractor, nil
+		}
+	}
+
+	return nil, &ScrapeError{
+		URL:       filename,
+		Err:       fmt.Errorf("no registered ArchiveExtractor matches: %s", filename),
+		Permanent: true,
+	}
+}
+
+type zipArchiveExtractor struct{}
+
+func (z *zipArchiveExtractor) Match(filename string) bool {
+	return strings.HasSuffix(filename, ".zip")
+}
+
+func (z *zipArchiveExtractor) Extract(r io.ReaderAt, size int64, want func(name string) bool) (io.ReadCloser, error) {
+	zipReader, zipErr := zip.NewReader(r, size)
+	if zipErr != nil {
+		return nil, zipErr
+	}
+
+	for _, zippedFile := range zipReader.File {
+		if want(zippedFile.Name) {
+			return zippedFile.Open()
+		}
+	}
+
+	return nil, fmt.Errorf("no matching member in zip")
+}
+
+// targzArchiveExtractor handles the .tar.gz retention buckets EDGAR has used
+// historically. gzip/tar are both forward-only, so unlike the zip case this
+// has to stream through the archive from the start looking for the wanted
+// member rather than seeking straight to it.
+type targzArchiveExtractor struct{}
+
+func (t *targzArchiveExtractor) Match(filename string) bool {
+	return strings.HasSuffix(filename, ".tar.gz") || strings.HasSuffix(filename, ".tgz")
+}
+
+func (t *targzArchiveExtractor) Extract(r io.ReaderAt, size int64, want func(name string) bool) (io.ReadCloser, error) {
+	gzReader, gzErr := gzip.NewReader(bufio.NewReaderSize(io.NewSectionReader(r, 0, size), archiveReadBufferSize))
+	if gzErr != nil {
+		return nil, gzErr
+	}
+	defer gzReader.Close()
+
+	tarReader := tar.NewReader(gzReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if want(header.Name) {
+			body, readErr := io.ReadAll(tarReader)
+			if readErr != nil {
+				return nil, readErr
+			}
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	return nil, fmt.Errorf("no matching member in tar.gz")
+}
+
+// rarArchiveExtractor covers the third-party mirrors that republish EDGAR
+// filings as .rar.
+type rarArchiveExtractor struct{}
+
+func (ra *rarArchiveExtractor) Match(filename string) bool {
+	return strings.HasSuffix(filename, ".rar")
+}
+
+func (ra *rarArchiveExtractor) Extract(r io.ReaderAt, size int64, want func(name string) bool) (io.ReadCloser, error) {
+	rarReader, rarErr := rardecode.NewReader(bufio.NewReaderSize(io.NewSectionReader(r, 0, size), archiveReadBufferSize), "")
+	if rarErr != nil {
+		return nil, rarErr
+	}
+
+	for {
+		header, err := rarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if want(header.Name) {
+			body, readErr := io.ReadAll(rarReader)
+			if readErr != nil {
+				return nil, readErr
+			}
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	return nil, fmt.Errorf("no matching member in rar")
+}
@@ -0,0 +1,155 @@
+package scraper
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+)
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create zip member %s: %v", name, err)
+		}
+		if _, err := w.Write([]byte(contents)); err != nil {
+			t.Fatalf("failed to write zip member %s: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip writer: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func buildTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for name, contents := range files {
+		hdr := &tar.Header{Name: name, Size: int64(len(contents)), Mode: 0644}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("failed to write tar header for %s: %v", name, err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatalf("failed to write tar member %s: %v", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar writer: %v", err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatalf("failed to close gzip writer: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestExtractorForMatchesBySuffix(t *testing.T) {
+	cases := []struct {
+		filename string
+		wantType ArchiveExtractor
+	}{
+		{"0001750-20-000123-xbrl.zip", &zipArchiveExtractor{}},
+		{"0001750-20-000123-xbrl.tar.gz", &targzArchiveExtractor{}},
+		{"0001750-20-000123-xbrl.rar", &rarArchiveExtractor{}},
+	}
+
+	for _, c := range cases {
+		extractor, err := extractorFor(c.filename)
+		if err != nil {
+			t.Fatalf("extractorFor(%q) returned error: %v", c.filename, err)
+		}
+
+		switch c.wantType.(type) {
+		case *zipArchiveExtractor:
+			if _, ok := extractor.(*zipArchiveExtractor); !ok {
+				t.Errorf("expected a zipArchiveExtractor for %q, got %T", c.filename, extractor)
+			}
+		case *targzArchiveExtractor:
+			if _, ok := extractor.(*targzArchiveExtractor); !ok {
+				t.Errorf("expected a targzArchiveExtractor for %q, got %T", c.filename, extractor)
+			}
+		case *rarArchiveExtractor:
+			if _, ok := extractor.(*rarArchiveExtractor); !ok {
+				t.Errorf("expected a rarArchiveExtractor for %q, got %T", c.filename, extractor)
+			}
+		}
+	}
+}
+
+func TestExtractorForReturnsErrorForUnknownSuffix(t *testing.T) {
+	if _, err := extractorFor("some-filing.7z"); err == nil {
+		t.Fatal("expected an error for an unregistered archive suffix")
+	}
+}
+
+func TestZipArchiveExtractorExtractsMatchingMember(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"readme.txt":  "not this one",
+		"report.xbrl": "the xbrl payload",
+	})
+
+	extractor := &zipArchiveExtractor{}
+	rc, err := extractor.Extract(bytes.NewReader(data), int64(len(data)), func(name string) bool {
+		return name == "report.xbrl"
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer rc.Close()
+
+	body, readErr := io.ReadAll(rc)
+	if readErr != nil {
+		t.Fatalf("failed to read extracted member: %v", readErr)
+	}
+	if string(body) != "the xbrl payload" {
+		t.Errorf("unexpected extracted contents: %q", body)
+	}
+}
+
+func TestZipArchiveExtractorReturnsErrorWhenNoMemberMatches(t *testing.T) {
+	data := buildZip(t, map[string]string{"readme.txt": "nothing useful"})
+
+	extractor := &zipArchiveExtractor{}
+	_, err := extractor.Extract(bytes.NewReader(data), int64(len(data)), func(name string) bool {
+		return name == "report.xbrl"
+	})
+	if err == nil {
+		t.Fatal("expected an error when no member matches")
+	}
+}
+
+func TestTargzArchiveExtractorExtractsMatchingMember(t *testing.T) {
+	data := buildTarGz(t, map[string]string{
+		"readme.txt":  "not this one",
+		"report.xbrl": "the xbrl payload",
+	})
+
+	extractor := &targzArchiveExtractor{}
+	rc, err := extractor.Extract(bytes.NewReader(data), int64(len(data)), func(name string) bool {
+		return name == "report.xbrl"
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer rc.Close()
+
+	body, readErr := io.ReadAll(rc)
+	if readErr != nil {
+		t.Fatalf("failed to read extracted member: %v", readErr)
+	}
+	if string(body) != "the xbrl payload" {
+		t.Errorf("unexpected extracted contents: %q", body)
+	}
+}
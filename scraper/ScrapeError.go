@@ -0,0 +1,22 @@
+package scraper
+
+import "fmt"
+
+// ScrapeError wraps a failure to fetch or extract a filing whose location we
+// already know. Modeled on the VCSError pattern in cmd/go's codehost: it
+// separates "retry me, this might clear up" from "we already know this
+// artifact is gone (a withdrawn filing, a 404), don't retry blindly." Only
+// the latter should ever be persisted as a permanent ScrapeState error.
+type ScrapeError struct {
+	URL       string
+	Err       error
+	Permanent bool
+}
+
+func (se *ScrapeError) Error() string {
+	return fmt.Sprintf("scrape failed for %s: %v", se.URL, se.Err)
+}
+
+func (se *ScrapeError) Unwrap() error {
+	return se.Err
+}
@@ -1,20 +1,31 @@
 package scraper
 
 import (
-	"archive/zip"
 	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"github.com/ProfessorBeekums/PbStockResearcher/filings"
 	"github.com/ProfessorBeekums/PbStockResearcher/log"
 	"github.com/ProfessorBeekums/PbStockResearcher/persist"
 	"github.com/ProfessorBeekums/PbStockResearcher/tmpStore"
+	"golang.org/x/time/rate"
 	"io"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// retry tuning for transient SEC throttling (429) and server errors (5xx).
+// A withdrawn filing (404) or malformed request is not retried.
+const maxFetchAttempts = 5
+const fetchBackoffBase = 500 * time.Millisecond
+
 // create a function to scrape an index given a year and quarter
 // the function will also take in a delay between loading each file
 // save results to a data store
@@ -25,20 +36,58 @@ const INDEX_FILE_NAME = "/xbrl.idx"
 
 const SEC_EDGAR_BASE_URL = "http://www.sec.gov/Archives/"
 const XBRL_ZIP_SUFFIX = "-xbrl.zip"
+const XBRL_TARGZ_SUFFIX = "-xbrl.tar.gz"
+const XBRL_RAR_SUFFIX = "-xbrl.rar"
+
+// xbrlArchiveSuffixes is tried in order against each suffix's configured
+// mirror (see archiveMirrors on EdgarFullIndexScraper). EDGAR itself only
+// ever serves the .zip form at SEC_EDGAR_BASE_URL; .tar.gz/.rar only get
+// tried against a mirror a caller has actually registered with
+// SetArchiveMirror, so a withdrawn filing doesn't cost extra HEAD requests
+// against hosts that were never going to serve it.
+var xbrlArchiveSuffixes = []string{XBRL_ZIP_SUFFIX, XBRL_TARGZ_SUFFIX, XBRL_RAR_SUFFIX}
 
 type EdgarFullIndexScraper struct {
 	year, quarter   int
 	ts              *tmpStore.TempStore
 	persister       persist.PersistCompany
 	reportPersister persist.PersistReportFiles
+	statePersister  persist.PersistScrapeState
+	stateMu         sync.Mutex
+	// limiter, when set by ScrapeConcurrent, throttles the actual outbound
+	// HTTP calls (HEAD + each Range GET) made while fetching a filing --
+	// not how fast index rows are handed to workers. Rows that short-
+	// circuit in processIndexEntry (already in tmpStore, already a
+	// recorded permanent error) never touch it.
+	limiter *rate.Limiter
+	// archiveMirrors maps an xbrlArchiveSuffixes entry to the base URL
+	// GetXbrl should fetch it from. XBRL_ZIP_SUFFIX defaults to
+	// SEC_EDGAR_BASE_URL since EDGAR always serves that form itself; the
+	// other suffixes are only tried once SetArchiveMirror registers a host
+	// that actually republishes filings in that format.
+	archiveMirrors map[string]string
 }
 
 func NewEdgarFullIndexScraper(year, quarter int,
 	ts *tmpStore.TempStore, persister persist.PersistCompany,
-	reportPersister persist.PersistReportFiles) *EdgarFullIndexScraper {
+	reportPersister persist.PersistReportFiles,
+	statePersister persist.PersistScrapeState) *EdgarFullIndexScraper {
 	return &EdgarFullIndexScraper{year: year,
 		quarter: quarter, ts: ts, persister: persister,
-		reportPersister: reportPersister}
+		reportPersister: reportPersister, statePersister: statePersister,
+		archiveMirrors: map[string]string{XBRL_ZIP_SUFFIX: SEC_EDGAR_BASE_URL}}
+}
+
+// SetArchiveMirror registers baseURL as the host GetXbrl fetches suffix
+// archives from (e.g. XBRL_TARGZ_SUFFIX -> "http://my-mirror.example/Archives/"),
+// making that ArchiveExtractor reachable. Suffixes with no registered
+// mirror are skipped entirely rather than probed against sec.gov, which
+// would never serve them.
+func (efis *EdgarFullIndexScraper) SetArchiveMirror(suffix, baseURL string) {
+	if efis.archiveMirrors == nil {
+		efis.archiveMirrors = make(map[string]string)
+	}
+	efis.archiveMirrors[suffix] = baseURL
 }
 
 func (efis *EdgarFullIndexScraper) ScrapeEdgarQuarterlyIndex() {
@@ -63,6 +112,234 @@ func (efis *EdgarFullIndexScraper) ScrapeEdgarQuarterlyIndex() {
 	}
 }
 
+// ScrapeConcurrent is the same crawl as ScrapeEdgarQuarterlyIndex, but fans
+// the per-filing work out over a bounded pool of workers instead of running
+// on the caller's goroutine. rps caps the combined rate of outbound HTTP
+// calls across all workers to stay under SEC's rate limit -- it gates the
+// HEAD/Range GETs made while actually fetching a filing, not how fast index
+// rows are dispatched to workers, so a resume that skips thousands of
+// already-done rows doesn't pay any rate-limit wait for rows it never
+// fetches anything for. ctx lets a caller cancel an in-progress crawl
+// (Ctrl-C, a deadline, ...) so in-flight downloads stop instead of running
+// to completion.
+func (efis *EdgarFullIndexScraper) ScrapeConcurrent(ctx context.Context, workers int, rps float64) error {
+	log.Println("Starting concurrent scrape of the full index for year <", efis.year,
+		"> and quarter:", efis.quarter, " with ", workers, " workers")
+
+	efis.limiter = rate.NewLimiter(rate.Limit(rps), 1)
+
+	indexUrl := EDGAR_FULL_INDEX_URL_PREFIX +
+		strconv.FormatInt(int64(efis.year), 10) +
+		"/QTR" + strconv.FormatInt(int64(efis.quarter), 10) + INDEX_FILE_NAME
+
+	req, reqErr := http.NewRequestWithContext(ctx, "GET", indexUrl, nil)
+	if reqErr != nil {
+		return reqErr
+	}
+
+	getResp, getErr := doWithRetry(http.DefaultClient, req, efis.limiter)
+	if getErr != nil {
+		return fmt.Errorf("failed to retrieve index for url <%s>: %v", indexUrl, getErr)
+	}
+	defer getResp.Body.Close()
+
+	entries, parseErr := efis.parseIndexEntries(getResp.Body)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	for _, entry := range entries {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(e indexEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			efis.processIndexEntry(ctx, e)
+		}(entry)
+	}
+
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// indexEntry is one row of a Full Index file.
+type indexEntry struct {
+	cik, companyName, formType, dateFiled, filename string
+}
+
+// parseIndexEntries reads a Full Index file into its constituent rows
+// without fetching anything, so the fetch fan-out in ScrapeConcurrent can be
+// driven off a plain slice.
+func (efis *EdgarFullIndexScraper) parseIndexEntries(fileReader io.Reader) ([]indexEntry, error) {
+	entries := make([]indexEntry, 0)
+
+	listBegun := false
+	var line []byte = nil
+	var readErr error = nil
+	var isPrefix bool = false
+
+	reader := bufio.NewReader(fileReader)
+	for readErr == nil {
+		line, isPrefix, readErr = reader.ReadLine()
+		if isPrefix {
+			log.Error("This index file has a line that's too long!")
+			continue
+		}
+
+		if line == nil {
+			continue
+		}
+
+		lineStr := string(line)
+		if !listBegun && strings.Contains(lineStr, "-------") {
+			listBegun = true
+			continue
+		}
+
+		if !listBegun {
+			continue
+		}
+
+		elements := strings.Split(lineStr, "|")
+		entries = append(entries, indexEntry{
+			cik:         elements[0],
+			companyName: elements[1],
+			formType:    elements[2],
+			dateFiled:   elements[3],
+			filename:    elements[4],
+		})
+	}
+
+	if readErr != io.EOF {
+		return entries, readErr
+	}
+
+	return entries, nil
+}
+
+// processIndexEntry is the per-filing body shared by the worker pool: persist
+// the company, skip filings already in tmpStore or already known-permanently
+// broken, otherwise fetch the xbrl. Safe to call concurrently from multiple
+// workers -- it touches no state other than what's threaded through the
+// receiver and the entry itself.
+func (efis *EdgarFullIndexScraper) processIndexEntry(ctx context.Context, entry indexEntry) {
+	cikInt, cikErr := strconv.Atoi(entry.cik)
+
+	if cikErr == nil {
+		company := &filings.Company{CIK: int64(cikInt), Name: entry.companyName}
+		efis.persister.InsertUpdateCompany(company)
+	} else {
+		log.Error("Failed to parse CIK to int: ", entry.cik)
+		return
+	}
+
+	log.Println("CIK: ", entry.cik, " Company Name: ", entry.companyName,
+		" Form type: ", entry.formType, "  Date Filed: ", entry.dateFiled,
+		"  FileName: ", entry.filename)
+
+	cik := int64(cikInt)
+
+	if state := efis.getScrapeState(cik, entry.formType); state != nil && state.Status == filings.ScrapeStatusPermanentError {
+		log.Println("SKIP <", entry.filename, "> because it was previously recorded as a permanent error: ", state.LastError)
+		return
+	}
+
+	bucket := getBucket(entry.cik)
+	fileKey := getKey(entry.formType, efis.year, efis.quarter)
+
+	filePath := efis.ts.GetFilePath(bucket, fileKey)
+
+	if filePath != "" {
+		log.Println("SKIP <", entry.filename, "> because it already exists in: ", filePath)
+		return
+	}
+
+	reportFile := &filings.ReportFile{CIK: cik,
+		Year:     int64(efis.year),
+		Quarter:  int64(efis.quarter),
+		Parsed:   false,
+		FormType: entry.formType}
+
+	if xbrlErr := efis.GetXbrl(ctx, entry.filename, bucket, fileKey, reportFile); xbrlErr != nil {
+		log.Error("Failed to get xbrl for <", entry.filename, "> with error: ", xbrlErr)
+		efis.recordScrapeResult(cik, entry.formType, xbrlErr)
+		return
+	}
+
+	efis.recordScrapeResult(cik, entry.formType, nil)
+}
+
+// getScrapeState is a nil-safe lookup -- statePersister is optional, since
+// not every caller needs resumability.
+func (efis *EdgarFullIndexScraper) getScrapeState(cik int64, formType string) *filings.ScrapeState {
+	if efis.statePersister == nil {
+		return nil
+	}
+
+	return efis.statePersister.GetScrapeState(cik, int64(efis.year), int64(efis.quarter), formType)
+}
+
+// recordScrapeResult stamps the outcome of one fetch/extract attempt into
+// ScrapeState. A nil scrapeErr means success; a *ScrapeError with Permanent
+// set means this key should be skipped on every future resume instead of
+// retried. The read-modify-write against statePersister is serialized by
+// stateMu so two workers racing on the same (CIK, formType) within a quarter
+// can't clobber each other's Attempts/Status.
+func (efis *EdgarFullIndexScraper) recordScrapeResult(cik int64, formType string, scrapeErr error) {
+	if efis.statePersister == nil {
+		return
+	}
+
+	efis.stateMu.Lock()
+	defer efis.stateMu.Unlock()
+
+	state := efis.getScrapeState(cik, formType)
+	if state == nil {
+		state = &filings.ScrapeState{CIK: cik, Year: int64(efis.year), Quarter: int64(efis.quarter), FormType: formType}
+	}
+
+	state.Attempts++
+
+	if scrapeErr == nil {
+		state.Status = filings.ScrapeStatusExtracted
+		state.LastError = ""
+	} else {
+		state.LastError = scrapeErr.Error()
+
+		var se *ScrapeError
+		if errors.As(scrapeErr, &se) && se.Permanent {
+			state.Status = filings.ScrapeStatusPermanentError
+		} else {
+			state.Status = filings.ScrapeStatusPending
+		}
+	}
+
+	efis.statePersister.InsertUpdateScrapeState(state)
+}
+
+// Resume re-runs ScrapeConcurrent for this scraper's year/quarter: the
+// quarter's index is fetched and walked again, but processIndexEntry skips
+// filings already in tmpStore and filings ScrapeState records as a
+// permanent error. That's what lets an interrupted multi-quarter backfill
+// pick back up where it left off instead of re-fetching every filing.
+func (efis *EdgarFullIndexScraper) Resume(ctx context.Context, workers int, rps float64) error {
+	if efis.statePersister == nil {
+		return errors.New("Resume requires a PersistScrapeState to have been set on this scraper")
+	}
+
+	return efis.ScrapeConcurrent(ctx, workers, rps)
+}
+
 // Parses a ReadCloser that contains a Full Index file. The caller is
 // responsible for closing the ReadCloser.
 func (efis *EdgarFullIndexScraper) ParseIndexFile(fileReader io.ReadCloser) {
@@ -118,7 +395,7 @@ func (efis *EdgarFullIndexScraper) ParseIndexFile(fileReader io.ReadCloser) {
 						Quarter:  int64(efis.quarter),
 						Parsed:   false,
 						FormType: formType}
-					efis.GetXbrl(filename, bucket, fileKey, reportFile)
+					efis.GetXbrl(context.Background(), filename, bucket, fileKey, reportFile)
 				} else {
 					log.Println("SKIP <", filename, "> because it already exists in: ", filePath)
 				}
@@ -127,76 +404,268 @@ func (efis *EdgarFullIndexScraper) ParseIndexFile(fileReader io.ReadCloser) {
 	}
 }
 
-// The full index provides links to txt files. We want to convert these to retrieve the corresponding zip of xbrl files
-// and extract the main xbrl file.
-func (efis *EdgarFullIndexScraper) GetXbrl(edgarFilename, bucket, fileKey string, reportFile *filings.ReportFile) {
+// The full index provides links to txt files. We want to convert these to
+// retrieve the corresponding archive of xbrl files and extract the main
+// xbrl file. Tries each suffix in xbrlArchiveSuffixes in turn against that
+// suffix's registered mirror (see archiveMirrors/SetArchiveMirror) -- not
+// just .zip against sec.gov -- so the tar.gz/rar ArchiveExtractors can
+// actually be reached. A suffix with no registered mirror is skipped
+// without making a request. A permanent not-found for one suffix just
+// means try the next one; any other error aborts immediately.
+func (efis *EdgarFullIndexScraper) GetXbrl(ctx context.Context, edgarFilename, bucket, fileKey string, reportFile *filings.ReportFile) error {
 	if !strings.Contains(edgarFilename, ".txt") {
 		log.Error("Unexpected file type: ", edgarFilename)
-		return
+		return fmt.Errorf("unexpected file type: %s", edgarFilename)
 	}
 
 	parts := strings.Split(edgarFilename, "/")
 	baseName := strings.Trim(parts[3], ".txt")
 	preBase := strings.Replace(baseName, "-", "", -1)
-	parts[3] = preBase + "/" + baseName + XBRL_ZIP_SUFFIX
 
-	fullUrl := SEC_EDGAR_BASE_URL + strings.Join(parts, "/")
+	var lastErr error
+	triedAny := false
+	for _, suffix := range xbrlArchiveSuffixes {
+		mirrorBaseURL, ok := efis.archiveMirrors[suffix]
+		if !ok {
+			continue
+		}
+		triedAny = true
 
-	log.Println("Getting xbrl zip from ", fullUrl)
+		parts[3] = preBase + "/" + baseName + suffix
+		fullUrl := mirrorBaseURL + strings.Join(parts, "/")
 
-	getResp, getErr := http.Get(fullUrl)
+		log.Println("Getting xbrl archive from ", fullUrl)
 
-	if getErr != nil {
-		log.Error("Failed get to: ", fullUrl)
-	} else {
-		defer getResp.Body.Close()
+		rangeReader, size, rangeErr := newHttpRangeReaderAt(ctx, fullUrl, efis.limiter)
+		if rangeErr != nil {
+			var scrapeErr *ScrapeError
+			if errors.As(rangeErr, &scrapeErr) && scrapeErr.Permanent {
+				lastErr = rangeErr
+				continue
+			}
 
-		outputFileName := strconv.Itoa(int(time.Now().Unix())) + baseName + XBRL_ZIP_SUFFIX
-		zipFilePath := efis.ts.StoreFile(bucket, outputFileName, getResp.Body)
+			log.Error("Failed to prepare ranged read of: ", fullUrl, " with error: ", rangeErr)
+			return rangeErr
+		}
 
-		if zipFilePath != "" {
-			efis.getXbrlFromZip(zipFilePath, bucket, fileKey, reportFile)
+		return efis.getXbrlFromArchive(rangeReader, size, fullUrl, bucket, fileKey, reportFile)
+	}
+
+	if !triedAny {
+		return fmt.Errorf("no archive mirror registered for any suffix to fetch %s", edgarFilename)
+	}
+
+	log.Error("No archive suffix matched for: ", edgarFilename, " last error: ", lastErr)
+	return lastErr
+}
+
+// getXbrlFromArchive dispatches to whichever ArchiveExtractor matches
+// archiveUrl's suffix, so only the member ArchiveExtractor actually extracts
+// -- not the rest of the bundle -- ever gets streamed to tmpStore.
+func (efis *EdgarFullIndexScraper) getXbrlFromArchive(r io.ReaderAt, size int64, archiveUrl, bucket, fileKey string, reportFile *filings.ReportFile) error {
+	extractor, extractorErr := extractorFor(archiveUrl)
+	if extractorErr != nil {
+		log.Error("Failed to find an archive extractor for: ", archiveUrl, " with error: ", extractorErr)
+		return extractorErr
+	}
+
+	xbrlFile, xbrlErr := extractor.Extract(r, size, isXbrlFileMatch)
+	if xbrlErr != nil {
+		log.Error("Could not find a match for an xbrl in archive at bucket <", bucket, "> key <", fileKey, ">: ", xbrlErr)
+
+		// A missing member or an archive that won't even parse is a
+		// deterministic property of this filing, not a transient fetch
+		// failure -- mark it permanent so Resume() doesn't re-fetch and
+		// re-fail the same filing forever.
+		var scrapeErr *ScrapeError
+		if errors.As(xbrlErr, &scrapeErr) {
+			return scrapeErr
 		}
+		// a cancelled crawl or a connection dropped mid ranged read is
+		// transient, so leave it retryable
+		if errors.Is(xbrlErr, context.Canceled) || errors.Is(xbrlErr, context.DeadlineExceeded) ||
+			errors.Is(xbrlErr, io.ErrUnexpectedEOF) {
+			return &ScrapeError{URL: archiveUrl, Err: xbrlErr, Permanent: false}
+		}
+		return &ScrapeError{URL: archiveUrl, Err: xbrlErr, Permanent: true}
 	}
+	defer xbrlFile.Close()
+
+	// hash while streaming so a repeat scrape can tell a byte-identical
+	// file from a genuine EDGAR amendment without re-parsing either one.
+	hasher := sha256.New()
+	reportPath := efis.ts.StoreFile(bucket, fileKey, io.TeeReader(xbrlFile, hasher))
+	reportFile.Filepath = reportPath
+	reportFile.ContentHash = hex.EncodeToString(hasher.Sum(nil))
+	efis.reportPersister.InsertUpdateReportFile(reportFile)
+	return nil
+}
+
+// httpRangeReaderAt implements io.ReaderAt by issuing a Range GET per call,
+// letting archive/zip pull only the bytes it actually needs (the end-of-
+// central-directory record, the central directory itself, and then the one
+// member we extract) instead of requiring the whole zip on disk up front.
+type httpRangeReaderAt struct {
+	ctx     context.Context
+	client  *http.Client
+	url     string
+	limiter *rate.Limiter
+	// size is the Content-Length learned at construction time. ReadAt needs
+	// it to tell "this short read is legitimate, the range ran off the end
+	// of the file" from "the connection dropped mid-read" -- without it,
+	// every short read looks the same and a dropped connection would
+	// silently hand the caller truncated bytes instead of an error.
+	size int64
 }
 
-func (efis *EdgarFullIndexScraper) getXbrlFromZip(zipFileName, bucket, fileKey string, reportFile *filings.ReportFile) {
-	zipReader, zipErr := zip.OpenReader(zipFileName)
+func newHttpRangeReaderAt(ctx context.Context, url string, limiter *rate.Limiter) (*httpRangeReaderAt, int64, error) {
+	r := &httpRangeReaderAt{ctx: ctx, client: http.DefaultClient, url: url, limiter: limiter}
 
-	if zipErr != nil {
-		log.Error("Failed to open zip: ", zipFileName, " with error: ", zipErr)
-	} else {
-		defer zipReader.Close()
+	size, err := r.contentLength()
+	if err != nil {
+		return nil, 0, err
+	}
+	r.size = size
 
-		foundOne := false
+	return r, size, nil
+}
 
-		for _, zippedFile := range zipReader.File {
-			zippedFileName := zippedFile.Name
-			isMatch := isXbrlFileMatch(zippedFileName)
-			if isMatch {
-				foundOne = true
-				log.Println("Found zipped file: ", zippedFileName)
+func (r *httpRangeReaderAt) contentLength() (int64, error) {
+	req, reqErr := http.NewRequestWithContext(r.ctx, "HEAD", r.url, nil)
+	if reqErr != nil {
+		return 0, reqErr
+	}
+
+	headResp, headErr := doWithRetry(r.client, req, r.limiter)
+	if headErr != nil {
+		return 0, headErr
+	}
+	defer headResp.Body.Close()
 
-				xbrlFile, xbrlErr := zippedFile.Open()
+	if headResp.ContentLength < 0 {
+		return 0, fmt.Errorf("server did not return a Content-Length for %s", r.url)
+	}
 
-				defer xbrlFile.Close()
+	return headResp.ContentLength, nil
+}
 
-				if xbrlErr != nil {
-					log.Error("Failed to open zip file")
-				} else {
-					reportPath := efis.ts.StoreFile(bucket, fileKey, xbrlFile)
-					reportFile.Filepath = reportPath
-					efis.reportPersister.InsertUpdateReportFile(reportFile)
-				}
+// ReadAt satisfies io.ReaderAt's contract: whenever it returns n < len(p) it
+// also returns a non-nil error. want is clamped to r.size so a request that
+// legitimately runs off the end of the file expects exactly that many
+// bytes -- if the response falls short of *that*, it's a dropped connection
+// or similar, not end-of-file, and must be reported as an error instead of
+// silently handing the caller truncated/zero-padded bytes.
+func (r *httpRangeReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
 
-				// we don't care about the other stuff
-				break
+	want := len(p)
+	if off+int64(want) > r.size {
+		want = int(r.size - off)
+	}
+
+	req, reqErr := http.NewRequestWithContext(r.ctx, "GET", r.url, nil)
+	if reqErr != nil {
+		return 0, reqErr
+	}
+
+	last := off + int64(want) - 1
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, last))
+
+	resp, respErr := doWithRetry(r.client, req, r.limiter)
+	if respErr != nil {
+		return 0, respErr
+	}
+	defer resp.Body.Close()
+
+	n, readErr := io.ReadFull(resp.Body, p[:want])
+	if readErr != nil {
+		return n, readErr
+	}
+
+	if want < len(p) {
+		// the range was clamped to the known end of the file -- n < len(p)
+		// is expected here, so the non-nil error io.ReaderAt requires is EOF.
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// doWithRetry issues req, retrying with exponential backoff on 429 and 5xx
+// responses -- a transient SEC throttle shouldn't drop a filing from a batch.
+// Any other status besides 200/206 is returned as a *ScrapeError without
+// retrying; it is marked Permanent only for a 4xx (other than 429). When
+// limiter is non-nil, every actual
+// send (the first attempt and every retry) waits on it first, so the
+// configured requests-per-second ceiling bounds real traffic to the server
+// rather than just how fast work is handed out.
+func doWithRetry(client *http.Client, req *http.Request, limiter *rate.Limiter) (*http.Response, error) {
+	var lastErr error
+
+	for attempt := 0; attempt < maxFetchAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := fetchBackoffBase * time.Duration(1<<uint(attempt-1))
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			}
+		}
+
+		if limiter != nil {
+			if waitErr := limiter.Wait(req.Context()); waitErr != nil {
+				return nil, waitErr
 			}
 		}
 
-		if foundOne == false {
-			log.Error("Could not find a match for an xbrl in ", zipFileName)
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("retryable status <%s> for %s", resp.Status, req.URL)
+			continue
 		}
+
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			// a 4xx other than 429 means we know exactly where the
+			// artifact is and the server is telling us it's not there
+			// (or the request is malformed) -- retrying won't help.
+			return nil, &ScrapeError{
+				URL:       req.URL.String(),
+				Err:       fmt.Errorf("unexpected status <%s>", resp.Status),
+				Permanent: resp.StatusCode >= 400 && resp.StatusCode < 500,
+			}
+		}
+
+		// A mirror that ignores our Range header and returns the full body
+		// with 200 would make ReadAt silently read the wrong bytes for any
+		// off > 0 instead of erroring -- require the 206 the Range header
+		// asked for.
+		if req.Header.Get("Range") != "" && resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			return nil, &ScrapeError{
+				URL:       req.URL.String(),
+				Err:       fmt.Errorf("sent Range header but got status <%s> instead of 206 Partial Content", resp.Status),
+				Permanent: true,
+			}
+		}
+
+		return resp, nil
+	}
+
+	return nil, &ScrapeError{
+		URL:       req.URL.String(),
+		Err:       fmt.Errorf("giving up after %d attempts: %v", maxFetchAttempts, lastErr),
+		Permanent: false,
 	}
 }
 
@@ -0,0 +1,440 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"github.com/ProfessorBeekums/PbStockResearcher/filings"
+	"golang.org/x/time/rate"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeScrapeStatePersister struct {
+	mu     sync.Mutex
+	states map[string]*filings.ScrapeState
+}
+
+func newFakeScrapeStatePersister() *fakeScrapeStatePersister {
+	return &fakeScrapeStatePersister{states: make(map[string]*filings.ScrapeState)}
+}
+
+func (f *fakeScrapeStatePersister) key(cik, year, quarter int64, formType string) string {
+	return fmt.Sprintf("%d:%d:%d:%s", cik, year, quarter, formType)
+}
+
+func (f *fakeScrapeStatePersister) GetScrapeState(cik, year, quarter int64, formType string) *filings.ScrapeState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.states[f.key(cik, year, quarter, formType)]
+}
+
+func (f *fakeScrapeStatePersister) InsertUpdateScrapeState(state *filings.ScrapeState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.states[f.key(state.CIK, state.Year, state.Quarter, state.FormType)] = state
+}
+
+func TestParseIndexEntriesSkipsHeaderAndParsesRows(t *testing.T) {
+	efis := &EdgarFullIndexScraper{}
+
+	raw := "Description:  Full Index\n" +
+		"Last Data Received: 12/31/2020\n" +
+		"Comments:     webmaster@sec.gov\n" +
+		"CIK|Company Name|Form Type|Date Filed|Filename\n" +
+		"-----------------------------------------------\n" +
+		"1750|AAR CORP|10-K|2020-08-14|edgar/data/1750/0000001750-20-000123.txt\n" +
+		"1800|ABBOTT LABORATORIES|10-Q|2020-10-20|edgar/data/1800/0000001800-20-000456.txt\n"
+
+	entries, err := efis.parseIndexEntries(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	if entries[0].cik != "1750" || entries[0].formType != "10-K" {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].cik != "1800" || entries[1].formType != "10-Q" {
+		t.Errorf("unexpected second entry: %+v", entries[1])
+	}
+}
+
+func TestDoWithRetryRetriesOnServerError(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := doWithRetry(server.Client(), req, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestDoWithRetryReturnsPermanentErrorWithoutRetrying(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	_, err := doWithRetry(server.Client(), req, nil)
+	if err == nil {
+		t.Fatal("expected an error for a 404")
+	}
+
+	scrapeErr, ok := err.(*ScrapeError)
+	if !ok {
+		t.Fatalf("expected a *ScrapeError, got %T", err)
+	}
+	if !scrapeErr.Permanent {
+		t.Errorf("expected a 404 to be recorded as permanent")
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected exactly 1 attempt for a permanent error, got %d", got)
+	}
+}
+
+// TestDoWithRetryHonorsLimiter pins a limiter to 2 requests per second and
+// confirms doWithRetry actually waits on it before sending -- this is what
+// keeps real outbound traffic under SEC's rate ceiling, not just how fast
+// index rows get dispatched to workers.
+func TestDoWithRetryHonorsLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	limiter := rate.NewLimiter(rate.Limit(2), 1)
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := doWithRetry(server.Client(), req, limiter)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	start := time.Now()
+
+	req2, _ := http.NewRequest("GET", server.URL, nil)
+	resp2, err := doWithRetry(server.Client(), req2, limiter)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resp2.Body.Close()
+
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("expected the second send to wait on the limiter, only waited %v", elapsed)
+	}
+}
+
+// TestDoWithRetryRejectsPlain200ForRangedRequest guards against a mirror
+// that ignores the Range header and returns the whole body with 200 --
+// accepting that as equivalent to 206 would make ReadAt silently return the
+// wrong bytes for any offset past zero instead of failing loudly.
+func TestDoWithRetryRejectsPlain200ForRangedRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("the entire file, ignoring Range"))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("Range", "bytes=10-19")
+
+	_, err := doWithRetry(server.Client(), req, nil)
+	if err == nil {
+		t.Fatal("expected an error when a ranged request gets back a plain 200")
+	}
+}
+
+func TestDoWithRetryAcceptsPartialContentForRangedRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("Range", "bytes=0-9")
+
+	resp, err := doWithRetry(server.Client(), req, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resp.Body.Close()
+}
+
+// TestGetXbrlOnlyProbesRegisteredMirrors proves GetXbrl only issues requests
+// for archive suffixes that actually have a mirror registered via
+// SetArchiveMirror -- a withdrawn filing shouldn't cost extra HEAD requests
+// against hosts that were never going to serve that suffix.
+func TestGetXbrlOnlyProbesRegisteredMirrors(t *testing.T) {
+	var zipHits, targzHits int32
+	zipMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&zipHits, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer zipMirror.Close()
+
+	targzMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&targzHits, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer targzMirror.Close()
+
+	efis := NewEdgarFullIndexScraper(2020, 1, nil, nil, nil, nil)
+	efis.SetArchiveMirror(XBRL_ZIP_SUFFIX, zipMirror.URL+"/")
+	efis.SetArchiveMirror(XBRL_TARGZ_SUFFIX, targzMirror.URL+"/")
+	// deliberately leave XBRL_RAR_SUFFIX unregistered
+
+	err := efis.GetXbrl(context.Background(), "edgar/data/1750/0000001750-20-000123.txt", "bucket", "key", nil)
+	if err == nil {
+		t.Fatal("expected an error since every registered mirror 404s")
+	}
+
+	if atomic.LoadInt32(&zipHits) != 1 {
+		t.Errorf("expected exactly 1 HEAD to the zip mirror, got %d", zipHits)
+	}
+	if atomic.LoadInt32(&targzHits) != 1 {
+		t.Errorf("expected exactly 1 HEAD to the targz mirror, got %d", targzHits)
+	}
+}
+
+// TestGetXbrlSkipsEntirelyWhenNoMirrorRegistered proves a scraper with no
+// registered mirrors at all (not even the default .zip one) makes no
+// requests and returns an error instead of silently doing nothing.
+func TestGetXbrlSkipsEntirelyWhenNoMirrorRegistered(t *testing.T) {
+	efis := &EdgarFullIndexScraper{}
+
+	err := efis.GetXbrl(context.Background(), "edgar/data/1750/0000001750-20-000123.txt", "bucket", "key", nil)
+	if err == nil {
+		t.Fatal("expected an error when no archive mirror is registered for any suffix")
+	}
+}
+
+func TestRecordScrapeResultClassifiesPermanentVsTransientErrors(t *testing.T) {
+	cases := []struct {
+		name       string
+		scrapeErr  error
+		wantStatus filings.ScrapeStatus
+	}{
+		{"success", nil, filings.ScrapeStatusExtracted},
+		{"permanent failure", &ScrapeError{URL: "x", Err: fmt.Errorf("gone"), Permanent: true}, filings.ScrapeStatusPermanentError},
+		{"transient failure", &ScrapeError{URL: "x", Err: fmt.Errorf("timeout"), Permanent: false}, filings.ScrapeStatusPending},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sp := newFakeScrapeStatePersister()
+			efis := &EdgarFullIndexScraper{year: 2020, quarter: 1, statePersister: sp}
+
+			efis.recordScrapeResult(1750, "10-K", c.scrapeErr)
+
+			state := sp.GetScrapeState(1750, 2020, 1, "10-K")
+			if state == nil {
+				t.Fatal("expected a recorded ScrapeState")
+			}
+			if state.Status != c.wantStatus {
+				t.Errorf("expected status %q, got %q", c.wantStatus, state.Status)
+			}
+			if state.Attempts != 1 {
+				t.Errorf("expected Attempts=1, got %d", state.Attempts)
+			}
+		})
+	}
+}
+
+// TestRecordScrapeResultSkipsPermanentErrorsOnResume exercises the same
+// interaction processIndexEntry relies on: once a key is recorded
+// permanent-error, getScrapeState reports it so a future Resume() can skip
+// it without re-fetching.
+func TestRecordScrapeResultSkipsPermanentErrorsOnResume(t *testing.T) {
+	sp := newFakeScrapeStatePersister()
+	efis := &EdgarFullIndexScraper{year: 2020, quarter: 1, statePersister: sp}
+
+	efis.recordScrapeResult(1750, "10-K", &ScrapeError{URL: "x", Err: fmt.Errorf("withdrawn"), Permanent: true})
+
+	state := efis.getScrapeState(1750, "10-K")
+	if state == nil || state.Status != filings.ScrapeStatusPermanentError {
+		t.Fatalf("expected a permanent-error state, got %+v", state)
+	}
+}
+
+// TestRecordScrapeResultIsSafeForConcurrentWorkers exercises recordScrapeResult
+// the way the ScrapeConcurrent worker pool actually calls it: many goroutines
+// racing a read-modify-write against the same (CIK, formType) key. stateMu
+// is what keeps Attempts from losing increments under the race detector.
+func TestRecordScrapeResultIsSafeForConcurrentWorkers(t *testing.T) {
+	sp := newFakeScrapeStatePersister()
+	efis := &EdgarFullIndexScraper{year: 2020, quarter: 1, statePersister: sp}
+
+	const workers = 20
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			efis.recordScrapeResult(1750, "10-K", nil)
+		}()
+	}
+	wg.Wait()
+
+	state := efis.getScrapeState(1750, "10-K")
+	if state == nil || state.Attempts != workers {
+		t.Fatalf("expected Attempts=%d after %d concurrent calls, got %+v", workers, workers, state)
+	}
+}
+
+func TestHttpRangeReaderAtReadsRequestedByteRange(t *testing.T) {
+	const full = "0123456789abcdefghij"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(full)))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		rangeHeader := r.Header.Get("Range")
+		var start, end int
+		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-%d", &start, &end); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if end >= len(full) {
+			end = len(full) - 1
+		}
+
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(full[start : end+1]))
+	}))
+	defer server.Close()
+
+	reader, size, err := newHttpRangeReaderAt(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if size != int64(len(full)) {
+		t.Fatalf("expected size %d, got %d", len(full), size)
+	}
+
+	buf := make([]byte, 5)
+	n, readErr := reader.ReadAt(buf, 10)
+	if readErr != nil {
+		t.Fatalf("expected no error, got %v", readErr)
+	}
+	if n != 5 || string(buf) != "abcde" {
+		t.Errorf("expected \"abcde\", got %q (n=%d)", buf, n)
+	}
+}
+
+// TestHttpRangeReaderAtReturnsEOFWhenRangeLegitimatelyRunsOffTheEnd proves
+// a short read that exactly reaches the known Content-Length is reported
+// with io.EOF (a non-nil error, as io.ReaderAt requires whenever n <
+// len(p)) rather than the old behavior of a nil error.
+func TestHttpRangeReaderAtReturnsEOFWhenRangeLegitimatelyRunsOffTheEnd(t *testing.T) {
+	const full = "0123456789"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(full)))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(full[5:]))
+	}))
+	defer server.Close()
+
+	reader, _, err := newHttpRangeReaderAt(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	buf := make([]byte, 10)
+	n, readErr := reader.ReadAt(buf, 5)
+	if readErr != io.EOF {
+		t.Fatalf("expected io.EOF for a read that runs off the known end, got %v", readErr)
+	}
+	if n != 5 || string(buf[:n]) != "56789" {
+		t.Errorf("expected \"56789\" (n=5), got %q (n=%d)", buf[:n], n)
+	}
+}
+
+// TestHttpRangeReaderAtErrorsOnDroppedConnection proves a short read that
+// does NOT correspond to the known end of the file is reported as an error
+// instead of silently handed to the caller as truncated/zero-padded bytes.
+func TestHttpRangeReaderAtErrorsOnDroppedConnection(t *testing.T) {
+	const full = "0123456789abcdefghij"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(full)))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// claim 206 but then only write part of the requested range and
+		// close the connection early, simulating a dropped connection.
+		w.Header().Set("Content-Length", "10")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(full[0:3]))
+	}))
+	defer server.Close()
+
+	reader, _, err := newHttpRangeReaderAt(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	buf := make([]byte, 10)
+	n, readErr := reader.ReadAt(buf, 0)
+	if readErr == nil {
+		t.Fatal("expected an error for a response that falls short of the requested (non-EOF) range")
+	}
+	if n == len(buf) {
+		t.Errorf("expected a short read to be reported, got the full buffer with no error")
+	}
+}
+
+func TestDoWithRetryNilLimiterDoesNotBlock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	resp, err := doWithRetry(server.Client(), req, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resp.Body.Close()
+}